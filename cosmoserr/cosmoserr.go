@@ -0,0 +1,146 @@
+// Package cosmoserr parses the error messages Azure Cosmos DB's Cassandra
+// API returns into a structured CosmosError, instead of callers having to
+// substring-match on wording that Cosmos DB is free to change.
+package cosmoserr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Known Cosmos DB substatus codes relevant to retry decisions. The full list
+// is much longer; these are the ones CosmosRetryPolicy currently acts on.
+const (
+	// SubStatusRequestRateTooLarge means the request was throttled because
+	// the collection/keyspace is out of provisioned throughput (RUs).
+	SubStatusRequestRateTooLarge = 3200
+	// SubStatusServerBusy means the Cosmos DB backend was too busy to serve
+	// the request; like SubStatusRequestRateTooLarge, it is safe to retry.
+	SubStatusServerBusy = 3202
+	// SubStatusPartitionKeyMismatch means the statement's partition key
+	// doesn't match the one the collection was created with. Retrying
+	// without changing the statement will never succeed.
+	SubStatusPartitionKeyMismatch = 1002
+	// SubStatusInvalidSchemaVersion means the client is using a schema the
+	// server no longer recognizes. Retrying won't help until the schema is
+	// fixed.
+	SubStatusInvalidSchemaVersion = 1004
+)
+
+// CosmosError is a structured Cosmos DB error parsed out of a driver error
+// message by Parse.
+type CosmosError struct {
+	// SubStatus is Cosmos DB's fine-grained status code, e.g. 3200 for rate
+	// limiting. -1 if the message didn't carry a Substatus token.
+	SubStatus int
+	// ActivityID identifies the request on the Cosmos DB side; include it
+	// when filing a support ticket.
+	ActivityID string
+	// RetryAfter is how long Cosmos DB asked the client to wait before
+	// retrying, or 0 if the message didn't include a RetryAfterMs token.
+	RetryAfter time.Duration
+	// Reason is the text after "Reason:" in the message, up to the next ','
+	// or ';'. A JSON payload that contains either separator is cut short
+	// there.
+	Reason string
+
+	// cause is the original error Parse was given, so CosmosError can
+	// participate in errors.As/errors.Is chains via Wrap.
+	cause error
+}
+
+// Error implements the error interface.
+func (e *CosmosError) Error() string {
+	if e.cause != nil {
+		return e.cause.Error()
+	}
+	return fmt.Sprintf("cosmos db error: substatus=%d activityId=%s", e.SubStatus, e.ActivityID)
+}
+
+// Unwrap returns the original error Wrap was given, so errors.Is/errors.As
+// keep working on the wrapped error too.
+func (e *CosmosError) Unwrap() error { return e.cause }
+
+// Parse extracts a CosmosError from a driver error message by tokenizing it
+// on ',' and ';' and looking for "Key: value" and "Key=value" tokens, rather
+// than substring-matching specific wording. ok is false if msg doesn't carry
+// at least one of the tokens Parse understands (Substatus, ActivityId,
+// RetryAfterMs, Reason).
+func Parse(msg string) (ce *CosmosError, ok bool) {
+	ce = &CosmosError{SubStatus: -1}
+
+	for _, tok := range tokenize(msg) {
+		key, val, found := splitToken(tok)
+		if !found {
+			continue
+		}
+		switch key {
+		case "Substatus":
+			if n, err := strconv.Atoi(val); err == nil {
+				ce.SubStatus = n
+				ok = true
+			}
+		case "ActivityId":
+			ce.ActivityID = val
+			ok = true
+		case "RetryAfterMs":
+			if n, err := strconv.Atoi(val); err == nil {
+				ce.RetryAfter = time.Duration(n) * time.Millisecond
+				ok = true
+			}
+		case "Reason":
+			ce.Reason = val
+			ok = true
+		}
+	}
+
+	if !ok {
+		return nil, false
+	}
+	return ce, true
+}
+
+// Wrap parses err's message into a CosmosError and returns it with err
+// chained as its Unwrap cause, so callers can write
+// errors.As(cosmoserr.Wrap(err), &ce) to recover the parsed fields while
+// errors.Is(err, ...) checks against err itself keep working. If err's
+// message doesn't parse as a Cosmos DB error, Wrap returns err unchanged.
+func Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	ce, ok := Parse(err.Error())
+	if !ok {
+		return err
+	}
+	ce.cause = err
+	return ce
+}
+
+// tokenize splits msg into trimmed, non-empty comma/semicolon-separated
+// tokens.
+func tokenize(msg string) []string {
+	replaced := strings.ReplaceAll(msg, ";", ",")
+	parts := strings.Split(replaced, ",")
+	tokens := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			tokens = append(tokens, p)
+		}
+	}
+	return tokens
+}
+
+// splitToken splits a "Key=value" or "Key: value" token into its key and
+// value.
+func splitToken(tok string) (key, val string, ok bool) {
+	if idx := strings.Index(tok, "="); idx != -1 {
+		return strings.TrimSpace(tok[:idx]), strings.TrimSpace(tok[idx+1:]), true
+	}
+	if idx := strings.Index(tok, ":"); idx != -1 {
+		return strings.TrimSpace(tok[:idx]), strings.TrimSpace(tok[idx+1:]), true
+	}
+	return "", "", false
+}
@@ -0,0 +1,43 @@
+package cosmoserr
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const rateLimitedErrMsg = `Request rate is large: ActivityID=c268afb6-7367-4ff8-b06b-b7e2d1269f55, RetryAfterMs=42, Additional details='Response status code does not indicate success: TooManyRequests (429); Substatus: 3200; ActivityId: c268afb6-7367-4ff8-b06b-b7e2d1269f55; Reason: (boom);`
+
+func TestParseRateLimitedError(t *testing.T) {
+	ce, ok := Parse(rateLimitedErrMsg)
+
+	assert.True(t, ok)
+	assert.Equal(t, SubStatusRequestRateTooLarge, ce.SubStatus)
+	assert.Equal(t, "c268afb6-7367-4ff8-b06b-b7e2d1269f55", ce.ActivityID)
+	assert.Equal(t, time.Duration(42)*time.Millisecond, ce.RetryAfter)
+}
+
+func TestParseUnrecognizedError(t *testing.T) {
+	_, ok := Parse("error: today is not your day!")
+
+	assert.False(t, ok)
+}
+
+func TestWrapRoundTripsWithErrorsAs(t *testing.T) {
+	original := errors.New(rateLimitedErrMsg)
+
+	wrapped := Wrap(original)
+
+	var ce *CosmosError
+	assert.True(t, errors.As(wrapped, &ce))
+	assert.Equal(t, SubStatusRequestRateTooLarge, ce.SubStatus)
+	assert.True(t, errors.Is(wrapped, original))
+}
+
+func TestWrapReturnsOriginalWhenUnparseable(t *testing.T) {
+	original := errors.New("error: today is not your day!")
+
+	assert.Equal(t, original, Wrap(original))
+}
@@ -0,0 +1,122 @@
+package retry
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstantBackoffReturnsFixedDelay(t *testing.T) {
+	b := ConstantBackoff{Delay: 250 * time.Millisecond}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		assert.Equal(t, 250*time.Millisecond, b.NextDelay(attempt, errors.New("boom")))
+	}
+}
+
+func TestExponentialBackoffDoublesByDefault(t *testing.T) {
+	b := ExponentialBackoff{Base: 100 * time.Millisecond}
+
+	assert.Equal(t, 100*time.Millisecond, b.NextDelay(0, nil))
+	assert.Equal(t, 200*time.Millisecond, b.NextDelay(1, nil))
+	assert.Equal(t, 400*time.Millisecond, b.NextDelay(2, nil))
+}
+
+func TestExponentialBackoffRespectsMultiplier(t *testing.T) {
+	b := ExponentialBackoff{Base: 100 * time.Millisecond, Multiplier: 3}
+
+	assert.Equal(t, 100*time.Millisecond, b.NextDelay(0, nil))
+	assert.Equal(t, 300*time.Millisecond, b.NextDelay(1, nil))
+	assert.Equal(t, 900*time.Millisecond, b.NextDelay(2, nil))
+}
+
+func TestExponentialBackoffClampsToMax(t *testing.T) {
+	b := ExponentialBackoff{Base: time.Second, Max: 5 * time.Second}
+
+	assert.Equal(t, 5*time.Second, b.NextDelay(10, nil))
+}
+
+func TestExponentialBackoffClampsOverflowWithoutMax(t *testing.T) {
+	b := ExponentialBackoff{Base: time.Second}
+
+	// Enough attempts to overflow float64 -> time.Duration if computed
+	// naively; NextDelay must saturate instead of wrapping negative.
+	assert.Positive(t, b.NextDelay(1000, nil))
+}
+
+func TestDecorrelatedJitterBackoffSeedsWithBaseOnFirstCall(t *testing.T) {
+	b := &DecorrelatedJitterBackoff{Base: 100 * time.Millisecond, Max: 2 * time.Second}
+
+	assert.Equal(t, 100*time.Millisecond, b.NextDelay(0, nil))
+}
+
+func TestDecorrelatedJitterBackoffStaysWithinBounds(t *testing.T) {
+	b := &DecorrelatedJitterBackoff{Base: 50 * time.Millisecond, Max: time.Second}
+
+	prev := b.NextDelay(0, nil)
+	assert.Equal(t, b.Base, prev)
+
+	for i := 1; i < 50; i++ {
+		next := b.NextDelay(i, nil)
+		assert.GreaterOrEqual(t, next, b.Base)
+		assert.LessOrEqual(t, next, b.Max)
+		prev = next
+	}
+	_ = prev
+}
+
+func TestDecorrelatedJitterBackoffIsSafeForConcurrentUse(t *testing.T) {
+	b := &DecorrelatedJitterBackoff{Base: time.Millisecond, Max: time.Second}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				b.NextDelay(j, nil)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// recordingStrategy records every attempt it was asked to compute a delay
+// for, so tests can assert on what CosmosRetryPolicy passes it.
+type recordingStrategy struct {
+	delay    time.Duration
+	attempts []int
+}
+
+func (s *recordingStrategy) NextDelay(attempt int, lastErr error) time.Duration {
+	s.attempts = append(s.attempts, attempt)
+	return s.delay
+}
+
+func TestNewCosmosRetryPolicyWithStrategyUsesStrategyForRateLimitedErrors(t *testing.T) {
+	strategy := &recordingStrategy{delay: 42 * time.Millisecond}
+	p := NewCosmosRetryPolicyWithStrategy(5, strategy)
+
+	assert.Same(t, strategy, p.Strategy)
+
+	delay := p.getRetryAfterMs(rateLimitedErrMsgWithoutRetryAfterMs)
+
+	assert.Equal(t, 42*time.Millisecond, delay)
+	assert.Equal(t, []int{0}, strategy.attempts)
+}
+
+func TestNewCosmosRetryPolicyWithStrategyReceivesPerQueryAttemptCount(t *testing.T) {
+	strategy := &recordingStrategy{delay: time.Millisecond}
+	p := NewCosmosRetryPolicyWithStrategy(-1, strategy)
+
+	// Simulate gocql calling Attempt before GetRetryType for the query's
+	// third try; the strategy should see attempt=2 (0-based), not a
+	// policy-lifetime total.
+	p.Attempt(fixedAttemptsQuery{attempts: 3})
+	p.GetRetryType(errors.New(rateLimitedErrMsgWithoutRetryAfterMs))
+
+	assert.Equal(t, []int{2}, strategy.attempts)
+}
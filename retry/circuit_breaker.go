@@ -0,0 +1,137 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitBreakerState is the state of the circuit breaker configured via
+// WithCircuitBreaker.
+type CircuitBreakerState int
+
+const (
+	// CircuitClosed is the normal state: requests are allowed through and
+	// 429s are tracked against CircuitBreakerConfig.FailureThreshold.
+	CircuitClosed CircuitBreakerState = iota
+	// CircuitOpen means the failure threshold was reached recently; every
+	// request is rejected (gocql.Rethrow) without being attempted, to give
+	// the throttled keyspace/partition room to recover.
+	CircuitOpen
+	// CircuitHalfOpen means CircuitBreakerConfig.CoolDown has elapsed since
+	// the breaker opened and a single trial request has been let through.
+	CircuitHalfOpen
+)
+
+// String implements fmt.Stringer.
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreakerConfig configures WithCircuitBreaker. It complements
+// CosmosRetryPolicy's retry/backoff behavior by shedding load once Cosmos DB
+// is clearly throttling a keyspace, rather than converting every request
+// into a slow-failing retry loop.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is how many Cosmos DB 429s, within Window, open the
+	// breaker.
+	FailureThreshold int
+	// Window is the rolling window over which 429s are counted. Every 429 in
+	// the window counts: requests that succeed or fail with another error in
+	// between do not reset the count.
+	Window time.Duration
+	// CoolDown is how long the breaker stays open before it allows a single
+	// half-open trial request through.
+	CoolDown time.Duration
+}
+
+// circuitBreaker tracks 429s for a single CosmosRetryPolicy and decides
+// whether to let a request through. gocql.RetryPolicy has no hook for a
+// successful response, so unlike a typical circuit breaker, the half-open
+// trial closes on a timeout (no further 429 within Window) rather than on
+// an explicit success signal; a 429 arriving while half-open reopens it
+// immediately.
+type circuitBreaker struct {
+	cfg CircuitBreakerConfig
+
+	mu         sync.Mutex
+	state      CircuitBreakerState
+	failures   []time.Time
+	openedAt   time.Time
+	halfOpenAt time.Time
+}
+
+func newCircuitBreaker(cfg CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{cfg: cfg}
+}
+
+// allow reports whether a request should be permitted at time now. changed
+// is true if calling allow caused a state transition, in which case from/to
+// describe it so the caller can notify its Observer outside the breaker's
+// lock.
+func (b *circuitBreaker) allow(now time.Time) (ok bool, from, to CircuitBreakerState, changed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.cfg.CoolDown {
+			return false, 0, 0, false
+		}
+		from = b.state
+		b.state = CircuitHalfOpen
+		b.halfOpenAt = now
+		return true, from, b.state, true
+	case CircuitHalfOpen:
+		if now.Sub(b.halfOpenAt) > b.cfg.Window {
+			from = b.state
+			b.state = CircuitClosed
+			b.failures = nil
+			return true, from, b.state, true
+		}
+		return false, 0, 0, false
+	default:
+		return true, 0, 0, false
+	}
+}
+
+// recordFailure registers a 429 observed at time now. changed is true if it
+// caused a state transition (closed -> open, or half-open -> open).
+func (b *circuitBreaker) recordFailure(now time.Time) (from, to CircuitBreakerState, changed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen {
+		from = b.state
+		b.state = CircuitOpen
+		b.openedAt = now
+		b.failures = nil
+		return from, b.state, true
+	}
+
+	b.failures = pruneBefore(append(b.failures, now), now.Add(-b.cfg.Window))
+	if b.state != CircuitOpen && len(b.failures) >= b.cfg.FailureThreshold {
+		from = b.state
+		b.state = CircuitOpen
+		b.openedAt = now
+		return from, b.state, true
+	}
+	return 0, 0, false
+}
+
+// pruneBefore removes timestamps at or before cutoff, in place.
+func pruneBefore(ts []time.Time, cutoff time.Time) []time.Time {
+	kept := ts[:0]
+	for _, t := range ts {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,77 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func TestCircuitBreakerOpensAfterConsecutiveRateLimitedErrors(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	obs := &recordingObserver{}
+	p := NewCosmosRetryPolicy(5,
+		WithClock(clock),
+		WithObserver(obs),
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, Window: time.Minute, CoolDown: time.Second}),
+	)
+
+	assert.Equal(t, gocql.Retry, p.GetRetryType(errors.New(rateLimitedErrMsg)))
+	assert.Equal(t, gocql.Retry, p.GetRetryType(errors.New(rateLimitedErrMsg)))
+
+	// breaker is now open: further requests are rejected without even being
+	// classified, regardless of how long we'd otherwise have retried.
+	clock.now = clock.now.Add(100 * time.Millisecond)
+	assert.Equal(t, gocql.Rethrow, p.GetRetryType(errors.New(rateLimitedErrMsg)))
+}
+
+func TestCircuitBreakerHalfOpensAfterCoolDown(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	p := NewCosmosRetryPolicy(5,
+		WithClock(clock),
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Window: time.Minute, CoolDown: time.Second}),
+	)
+
+	assert.Equal(t, gocql.Retry, p.GetRetryType(errors.New(rateLimitedErrMsg)))
+	clock.now = clock.now.Add(100 * time.Millisecond)
+	assert.Equal(t, gocql.Rethrow, p.GetRetryType(errors.New(rateLimitedErrMsg)))
+
+	// cool-down elapses: the breaker lets a single half-open trial through.
+	clock.now = clock.now.Add(time.Second)
+	assert.Equal(t, gocql.Retry, p.GetRetryType(errors.New(rateLimitedErrMsg)))
+}
+
+func TestCircuitBreakerNotifiesObserverOnOpen(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var transitions []CircuitBreakerState
+	obs := &stateRecordingObserver{onChange: func(from, to CircuitBreakerState) {
+		transitions = append(transitions, to)
+	}}
+	p := NewCosmosRetryPolicy(5,
+		WithClock(clock),
+		WithObserver(obs),
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Window: time.Minute, CoolDown: time.Second}),
+	)
+
+	p.GetRetryType(errors.New(rateLimitedErrMsg))
+
+	assert.Equal(t, []CircuitBreakerState{CircuitOpen}, transitions)
+}
+
+type stateRecordingObserver struct {
+	recordingObserver
+	onChange func(from, to CircuitBreakerState)
+}
+
+func (o *stateRecordingObserver) OnCircuitBreakerStateChange(ctx context.Context, from, to CircuitBreakerState) {
+	o.onChange(from, to)
+}
@@ -0,0 +1,456 @@
+// Package retry provides a gocql.RetryPolicy implementation tuned for Azure
+// Cosmos DB's Cassandra API, which throttles requests with 429 ("Request
+// rate is large") responses instead of the backpressure signals a native
+// Cassandra cluster would emit.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/AzureAdvocateBit/cosmos-cassandra-go-extension-1/cosmoserr"
+	"github.com/gocql/gocql"
+)
+
+// BackoffStrategy computes how long to wait before the next retry attempt.
+// Implementations are consulted by CosmosRetryPolicy whenever a 429 response
+// does not carry a RetryAfterMs hint, so they should be safe to call
+// concurrently if the same CosmosRetryPolicy is shared across goroutines.
+type BackoffStrategy interface {
+	// NextDelay returns how long to wait before retrying. attempt is the
+	// number of retries already made for the query (0 on the first retry)
+	// and lastErr is the error that triggered the retry.
+	NextDelay(attempt int, lastErr error) time.Duration
+}
+
+// ConstantBackoff waits the same fixed Delay before every retry.
+type ConstantBackoff struct {
+	Delay time.Duration
+}
+
+// NextDelay implements BackoffStrategy.
+func (b ConstantBackoff) NextDelay(attempt int, lastErr error) time.Duration {
+	return b.Delay
+}
+
+// ExponentialBackoff doubles (or scales by Multiplier) the delay on every
+// attempt, starting at Base and never exceeding Max.
+type ExponentialBackoff struct {
+	Base       time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// NextDelay implements BackoffStrategy.
+func (b ExponentialBackoff) NextDelay(attempt int, lastErr error) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	// maxDelay bounds the loop below so a large attempt count saturates the
+	// delay instead of overflowing float64 -> time.Duration, which converts
+	// out-of-int64-range floats to an implementation-defined (in practice,
+	// negative) value rather than clamping.
+	const maxDelay = float64(math.MaxInt64)
+	delay := float64(b.Base)
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= multiplier
+	}
+	var next time.Duration
+	if delay >= maxDelay {
+		next = math.MaxInt64
+	} else {
+		next = time.Duration(delay)
+	}
+	if b.Max > 0 && next > b.Max {
+		next = b.Max
+	}
+	return next
+}
+
+// DecorrelatedJitterBackoff implements the "decorrelated jitter" backoff
+// recurrence (sleep = min(max, random_between(base, sleep*3))), seeded with
+// sleep=base on the first call. Unlike plain exponential backoff, each retry
+// is randomized relative to the previous sleep rather than the attempt
+// count, which spreads out retries from many gocql sessions that are being
+// throttled by Cosmos DB at the same time instead of letting them resync
+// into a thundering herd.
+type DecorrelatedJitterBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+
+	mu    sync.Mutex
+	sleep time.Duration
+}
+
+// NextDelay implements BackoffStrategy.
+func (b *DecorrelatedJitterBackoff) NextDelay(attempt int, lastErr error) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.sleep <= 0 {
+		b.sleep = b.Base
+		return b.sleep
+	}
+
+	upper := float64(b.sleep) * 3
+	lower := float64(b.Base)
+	if upper <= lower {
+		upper = lower
+	}
+	next := time.Duration(lower + rand.Float64()*(upper-lower))
+	if b.Max > 0 && next > b.Max {
+		next = b.Max
+	}
+	b.sleep = next
+	return b.sleep
+}
+
+// Clock abstracts time.Now so tests can control what CosmosRetryPolicy
+// observes as the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// ErrMaxAttemptsExceeded is the sentinel wrapped by MaxAttemptsError. Check
+// for it with errors.Is to distinguish "gave up after the configured number
+// of attempts" from other non-retryable errors.
+var ErrMaxAttemptsExceeded = errors.New("cosmos retry policy: max attempts exceeded")
+
+// MaxAttemptsError is returned, wrapped, from GetRetryType once the hard cap
+// configured with WithMaxAttempts has been reached. GiveUpAt records when
+// (according to the policy's Clock) the cap was hit, for correlating with
+// logs or traces.
+type MaxAttemptsError struct {
+	Attempts int
+	GiveUpAt time.Time
+	Cause    error
+}
+
+func (e *MaxAttemptsError) Error() string {
+	return fmt.Sprintf("cosmos retry policy: gave up after %d attempts: %v", e.Attempts, e.Cause)
+}
+
+// Is reports whether target is ErrMaxAttemptsExceeded, so callers can use
+// errors.Is(err, ErrMaxAttemptsExceeded) without caring about the attempt
+// count or the underlying cause.
+func (e *MaxAttemptsError) Is(target error) bool { return target == ErrMaxAttemptsExceeded }
+
+// Unwrap returns the error that triggered the last retry attempt.
+func (e *MaxAttemptsError) Unwrap() error { return e.Cause }
+
+// CosmosRetryPolicy is a gocql.RetryPolicy that recognizes Cosmos DB's 429
+// ("Request rate is large") responses and retries them using the
+// RetryAfterMs hint Cosmos DB returns, falling back to FixedBackOffTimeMs
+// (or, for an infinite retry budget, a backoff that grows with the number of
+// attempts already made) when that hint is missing.
+type CosmosRetryPolicy struct {
+	// MaxRetryCount is the maximum number of retries to attempt. -1 means
+	// retry forever. It also selects which backoff is used when a 429
+	// response lacks a RetryAfterMs hint: finite values use
+	// FixedBackOffTimeMs, -1 uses GrowingBackOffTimeMs.
+	MaxRetryCount int
+
+	// FixedBackOffTimeMs is the delay, in milliseconds, used when a 429
+	// response does not include a RetryAfterMs hint and MaxRetryCount is
+	// finite.
+	FixedBackOffTimeMs int
+
+	// GrowingBackOffTimeMs is the base delay, in milliseconds, used to grow
+	// the backoff with the number of attempts when MaxRetryCount is
+	// infinite and a 429 response does not include a RetryAfterMs hint.
+	GrowingBackOffTimeMs int
+
+	// Strategy, when set, replaces FixedBackOffTimeMs/GrowingBackOffTimeMs
+	// for computing the delay when a 429 response does not include a
+	// RetryAfterMs hint.
+	Strategy BackoffStrategy
+
+	// Backup, when set, enables hedged/backup requests for queries executed
+	// through a HedgedSession built from this policy.
+	Backup *BackupRequestPolicy
+
+	// Observer, when set, is notified of every retry decision the policy
+	// makes; see the Observer doc comment.
+	Observer Observer
+
+	breaker *circuitBreaker
+
+	// mu guards attempts, numAttempts, lastErr, lastCosmosErr and lastCtx,
+	// which Attempt and GetRetryType update as they run. gocql shares one
+	// CosmosRetryPolicy across all of a session's connections, so these calls
+	// can race across goroutines. maxAttempts, clock and retryableErrs are
+	// set by options at construction and are read without mu.
+	mu sync.Mutex
+
+	// attempts is q.Attempts() as of the most recent call to Attempt, i.e.
+	// the number of attempts already made on the query GetRetryType is about
+	// to classify. It is deliberately per-query, not a running total across
+	// the policy's lifetime, so that the hard cap set with WithMaxAttempts
+	// means "N attempts of this query" rather than "N attempts anywhere in
+	// the session".
+	attempts int
+
+	// numAttempts counts 429 responses classified by getRetryAfterMs across
+	// the policy's lifetime, and feeds GrowingBackOffTimeMs. Unlike attempts,
+	// this is intentionally a running total: it exists to spread out retries
+	// from a sustained throttling episode, not to cap a single query.
+	numAttempts int
+
+	maxAttempts   int
+	clock         Clock
+	retryableErrs func(error) bool
+	lastErr       error
+	lastCosmosErr *cosmoserr.CosmosError
+	lastCtx       context.Context
+}
+
+// Option configures a CosmosRetryPolicy created by NewCosmosRetryPolicy or
+// NewCosmosRetryPolicyWithStrategy.
+type Option func(*CosmosRetryPolicy)
+
+// WithMaxAttempts caps the total number of attempts (including the first)
+// the policy will allow, independently of MaxRetryCount; whichever limit is
+// reached first stops the retries. Once the cap is reached GetRetryType returns
+// gocql.Rethrow and records a *MaxAttemptsError retrievable via Err. n <= 0
+// disables the cap.
+func WithMaxAttempts(n int) Option {
+	return func(p *CosmosRetryPolicy) { p.maxAttempts = n }
+}
+
+// WithFixedBackoff overrides the default FixedBackOffTimeMs.
+func WithFixedBackoff(d time.Duration) Option {
+	return func(p *CosmosRetryPolicy) { p.FixedBackOffTimeMs = int(d / time.Millisecond) }
+}
+
+// WithGrowingBackoff overrides the default GrowingBackOffTimeMs.
+func WithGrowingBackoff(d time.Duration) Option {
+	return func(p *CosmosRetryPolicy) { p.GrowingBackOffTimeMs = int(d / time.Millisecond) }
+}
+
+// WithClock overrides the Clock used to timestamp MaxAttemptsError. Intended
+// for tests; production callers can leave it unset.
+func WithClock(c Clock) Option {
+	return func(p *CosmosRetryPolicy) { p.clock = c }
+}
+
+// WithRetryableErrors registers a predicate consulted by GetRetryType before
+// its built-in classification. When it returns true for an error, that error
+// is retried even if it is not a recognized gocql timeout or a Cosmos DB 429.
+func WithRetryableErrors(f func(error) bool) Option {
+	return func(p *CosmosRetryPolicy) { p.retryableErrs = f }
+}
+
+// WithBackupRequests enables hedged/backup requests for queries executed
+// through a HedgedSession built from this policy; see BackupRequestPolicy.
+func WithBackupRequests(backup BackupRequestPolicy) Option {
+	return func(p *CosmosRetryPolicy) { p.Backup = &backup }
+}
+
+// WithObserver registers an Observer to be notified of every retry decision
+// the policy makes.
+func WithObserver(o Observer) Option {
+	return func(p *CosmosRetryPolicy) { p.Observer = o }
+}
+
+// WithCircuitBreaker enables a circuit breaker that shortcuts straight to
+// gocql.Rethrow once cfg.FailureThreshold Cosmos DB 429s have been seen
+// within cfg.Window, instead of retrying every request into a throttled
+// keyspace; see CircuitBreakerConfig.
+func WithCircuitBreaker(cfg CircuitBreakerConfig) Option {
+	return func(p *CosmosRetryPolicy) { p.breaker = newCircuitBreaker(cfg) }
+}
+
+// NewCosmosRetryPolicy creates a CosmosRetryPolicy with the default fixed and
+// growing backoff times, then applies opts. Pass -1 for maxRetryCount to
+// retry forever.
+func NewCosmosRetryPolicy(maxRetryCount int, opts ...Option) *CosmosRetryPolicy {
+	p := &CosmosRetryPolicy{
+		MaxRetryCount:        maxRetryCount,
+		FixedBackOffTimeMs:   5000,
+		GrowingBackOffTimeMs: 1000,
+		clock:                realClock{},
+		lastCtx:              context.Background(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// NewCosmosRetryPolicyWithStrategy creates a CosmosRetryPolicy that uses the
+// given BackoffStrategy to compute the delay whenever a 429 response does
+// not include a RetryAfterMs hint, instead of the fixed/growing backoff
+// times NewCosmosRetryPolicy falls back to.
+func NewCosmosRetryPolicyWithStrategy(maxRetryCount int, s BackoffStrategy, opts ...Option) *CosmosRetryPolicy {
+	p := NewCosmosRetryPolicy(maxRetryCount, opts...)
+	p.Strategy = s
+	return p
+}
+
+// Attempt implements gocql.RetryPolicy. It allows a retry as long as the
+// query has not exceeded MaxRetryCount attempts already (or always, when
+// MaxRetryCount is -1), and never beyond the hard cap set with
+// WithMaxAttempts. gocql calls Attempt before GetRetryType for every failed
+// attempt, so this is also where GetRetryType's own per-query state
+// (lastCtx, attempts) is captured.
+func (p *CosmosRetryPolicy) Attempt(q gocql.RetryableQuery) bool {
+	p.mu.Lock()
+	p.lastCtx = q.Context()
+	p.attempts = q.Attempts()
+	p.mu.Unlock()
+
+	if p.maxAttempts > 0 && q.Attempts() >= p.maxAttempts {
+		return false
+	}
+	if p.MaxRetryCount == -1 {
+		return true
+	}
+	return q.Attempts() <= p.MaxRetryCount
+}
+
+// Err returns the error recorded the last time the policy gave up retrying
+// because the hard attempt cap was reached, or nil if that has never
+// happened. Use errors.As(p.Err(), &target) or errors.Is(p.Err(),
+// ErrMaxAttemptsExceeded) to distinguish it from other non-retryable errors.
+func (p *CosmosRetryPolicy) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastErr
+}
+
+// LastCosmosError returns the CosmosError parsed out of the most recent
+// error GetRetryType classified as a Cosmos DB response, or nil if none has
+// been seen yet. Applications can use this to log the ActivityID for
+// support tickets; see also cosmoserr.Wrap for recovering it via errors.As
+// from an error returned by the driver directly.
+func (p *CosmosRetryPolicy) LastCosmosError() *cosmoserr.CosmosError {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastCosmosErr
+}
+
+// isRetryableSubStatus reports whether a Cosmos DB substatus code indicates
+// a transient condition worth retrying.
+func isRetryableSubStatus(subStatus int) bool {
+	switch subStatus {
+	case cosmoserr.SubStatusRequestRateTooLarge, cosmoserr.SubStatusServerBusy:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetRetryType implements gocql.RetryPolicy. It retries gocql's own timeout
+// and unavailable errors, errors accepted by WithRetryableErrors, and
+// transient Cosmos DB responses (rate limiting, server busy), and gives up
+// (gocql.Rethrow) on everything else - including non-transient Cosmos DB
+// responses such as a partition-key or schema mismatch, once the hard cap
+// set with WithMaxAttempts has been reached for the query most recently seen
+// via Attempt, and for every error while the circuit breaker set with
+// WithCircuitBreaker rejects requests.
+func (p *CosmosRetryPolicy) GetRetryType(err error) gocql.RetryType {
+	p.mu.Lock()
+	ctx, attempts := p.lastCtx, p.attempts
+	p.mu.Unlock()
+
+	if p.breaker != nil {
+		ok, from, to, changed := p.breaker.allow(p.clock.Now())
+		if changed {
+			p.notifyCircuitBreakerStateChange(ctx, from, to)
+		}
+		if !ok {
+			p.notifyGiveUp(ctx, attempts, err)
+			return gocql.Rethrow
+		}
+	}
+
+	if p.maxAttempts > 0 && attempts >= p.maxAttempts {
+		maxErr := &MaxAttemptsError{Attempts: attempts, GiveUpAt: p.clock.Now(), Cause: err}
+		p.mu.Lock()
+		p.lastErr = maxErr
+		p.mu.Unlock()
+		p.notifyGiveUp(ctx, attempts, maxErr)
+		return gocql.Rethrow
+	}
+
+	if p.retryableErrs != nil && p.retryableErrs(err) {
+		p.notifyRetry(ctx, attempts, 0, err)
+		return gocql.Retry
+	}
+
+	switch err.(type) {
+	case *gocql.RequestErrReadTimeout, *gocql.RequestErrWriteTimeout, *gocql.RequestErrUnavailable:
+		p.notifyRetry(ctx, attempts, 0, err)
+		return gocql.Retry
+	}
+
+	if delay := p.getRetryAfterMs(err.Error()); delay >= 0 {
+		if p.breaker != nil {
+			if from, to, changed := p.breaker.recordFailure(p.clock.Now()); changed {
+				p.notifyCircuitBreakerStateChange(ctx, from, to)
+			}
+		}
+		p.notifyRetry(ctx, attempts, delay, err)
+		return gocql.Retry
+	}
+
+	p.notifyGiveUp(ctx, attempts, err)
+	return gocql.Rethrow
+}
+
+// getRetryAfterMs returns how long to wait before retrying a request that
+// failed with errMsg, or -1 if errMsg is not a transient Cosmos DB error
+// (see isRetryableSubStatus). When errMsg carries Cosmos DB's own
+// RetryAfterMs hint that value is used as-is; otherwise the delay falls
+// back to Strategy, if set, or to FixedBackOffTimeMs/GrowingBackOffTimeMs.
+func (p *CosmosRetryPolicy) getRetryAfterMs(errMsg string) time.Duration {
+	ce, ok := cosmoserr.Parse(errMsg)
+	if !ok || !isRetryableSubStatus(ce.SubStatus) {
+		return time.Duration(-1)
+	}
+
+	p.mu.Lock()
+	p.lastCosmosErr = ce
+	p.numAttempts++
+	numAttempts, attempts, ctx := p.numAttempts, p.attempts, p.lastCtx
+	p.mu.Unlock()
+
+	var delay time.Duration
+	switch {
+	case ce.RetryAfter > 0:
+		delay = ce.RetryAfter
+	case p.Strategy != nil:
+		// attempts is q.Attempts() for this query as of the last Attempt
+		// call, i.e. 1 on the first retry; Strategy.NextDelay wants 0 there,
+		// so shift it down rather than passing numAttempts, which is a
+		// lifetime total across every query the shared policy has handled.
+		strategyAttempt := attempts - 1
+		if strategyAttempt < 0 {
+			strategyAttempt = 0
+		}
+		delay = p.Strategy.NextDelay(strategyAttempt, ce)
+	case p.MaxRetryCount == -1:
+		delay = time.Duration(p.GrowingBackOffTimeMs*(numAttempts+1)) * time.Millisecond
+	default:
+		delay = time.Duration(p.FixedBackOffTimeMs) * time.Millisecond
+	}
+	if delay < 0 {
+		// A Strategy that overflowed or otherwise misbehaved must not be
+		// mistaken by GetRetryType for "not retryable" (delay < 0); treat it
+		// as "wait as long as possible" instead of rethrowing a transient 429.
+		delay = time.Duration(math.MaxInt64)
+	}
+
+	p.notifyRateLimited(ctx, delay, ce.ActivityID)
+	return delay
+}
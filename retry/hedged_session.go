@@ -0,0 +1,220 @@
+package retry
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// BackupRequestPolicy enables Kitex-style backup (hedged) requests: if the
+// primary attempt has not succeeded within RetryDelayMs, a duplicate query is
+// launched against another coordinator (and, for a multi-region Cosmos DB
+// account, potentially another region) and whichever response comes back
+// first wins; the rest are abandoned. It complements CosmosRetryPolicy,
+// which only reacts once a request has already failed, by also hiding
+// latency outliers on requests that eventually succeed.
+type BackupRequestPolicy struct {
+	// RetryDelayMs is how long to wait for an attempt before firing the next
+	// backup request.
+	RetryDelayMs int
+
+	// MaxBackupRequests caps how many backup requests may be in flight for a
+	// single query, in addition to the primary attempt.
+	MaxBackupRequests int
+}
+
+// IsIdempotent reports whether stmt is safe to send as a backup request.
+// Plain INSERT/UPDATE/DELETE statements are idempotent, but lightweight
+// transactions ("IF [NOT] EXISTS", and other "IF <condition>" clauses) and
+// counter updates are not: executing the same statement twice can change the
+// outcome, so HedgedQuery refuses to hedge them. This is a statement-text
+// heuristic, not schema-aware analysis, so callers with counter columns that
+// don't mention "counter" in the statement should avoid hedging those
+// queries explicitly.
+func IsIdempotent(stmt string) bool {
+	upper := strings.ToUpper(stmt)
+	if strings.Contains(upper, " IF ") || strings.HasSuffix(strings.TrimSpace(upper), " IF") {
+		return false
+	}
+	if strings.Contains(upper, "COUNTER") {
+		return false
+	}
+	return true
+}
+
+// HedgedSession wraps a *gocql.Session to add ExecHedged/ScanHedged, which
+// race a duplicate ("backup") request against the original when the policy
+// carried by CosmosRetryPolicy.Backup is set. gocql.RetryPolicy cannot spawn
+// concurrent requests on its own, which is why this lives alongside it as a
+// session wrapper rather than another RetryPolicy method.
+type HedgedSession struct {
+	*gocql.Session
+
+	policy *CosmosRetryPolicy
+}
+
+// NewHedgedSession wraps session so queries built from it can be executed
+// with Query(...).ExecHedged and Query(...).Iter().ScanHedged. policy.Backup
+// controls whether, and how aggressively, backup requests are sent; a nil
+// Backup (or a nil policy) makes ExecHedged/ScanHedged behave exactly like
+// Exec/Scan.
+func NewHedgedSession(session *gocql.Session, policy *CosmosRetryPolicy) *HedgedSession {
+	return &HedgedSession{Session: session, policy: policy}
+}
+
+// Query returns a HedgedQuery wrapping the underlying *gocql.Query, so
+// callers can still use every gocql.Query method and additionally call
+// ExecHedged or Iter().ScanHedged.
+func (s *HedgedSession) Query(stmt string, values ...interface{}) *HedgedQuery {
+	return &HedgedQuery{Query: s.Session.Query(stmt, values...), session: s, stmt: stmt}
+}
+
+// HedgedQuery wraps a *gocql.Query with hedged execution.
+type HedgedQuery struct {
+	*gocql.Query
+
+	session *HedgedSession
+	stmt    string
+}
+
+// ExecHedged executes the query, sending up to policy.Backup.MaxBackupRequests
+// additional copies spaced policy.Backup.RetryDelayMs apart if earlier
+// attempts haven't succeeded yet, and returns as soon as any attempt
+// succeeds. It falls back to a single, unhedged Exec when no backup policy
+// is configured or the statement is not safe to retry concurrently (see
+// IsIdempotent).
+func (q *HedgedQuery) ExecHedged(ctx context.Context) error {
+	if q.attempts() <= 1 {
+		return q.Query.WithContext(ctx).Exec()
+	}
+	_, err := raceAttempts(ctx, q.attempts(), q.delay(), func(c context.Context) (struct{}, error) {
+		return struct{}{}, q.Query.WithContext(c).Exec()
+	}, nil)
+	return err
+}
+
+// Iter returns a HedgedIter for this query.
+func (q *HedgedQuery) Iter() *HedgedIter {
+	return &HedgedIter{query: q}
+}
+
+func (q *HedgedQuery) attempts() int {
+	policy := q.session.policy
+	if policy == nil {
+		return 1
+	}
+	backup := policy.Backup
+	if backup == nil || backup.MaxBackupRequests <= 0 || !IsIdempotent(q.stmt) {
+		return 1
+	}
+	return backup.MaxBackupRequests + 1
+}
+
+func (q *HedgedQuery) delay() time.Duration {
+	if backup := q.session.policy.Backup; backup != nil {
+		return time.Duration(backup.RetryDelayMs) * time.Millisecond
+	}
+	return 0
+}
+
+// HedgedIter wraps a not-yet-executed HedgedQuery so its eventual
+// *gocql.Iter can itself be raced across backup requests.
+type HedgedIter struct {
+	query *HedgedQuery
+}
+
+// ScanHedged runs the query the same way ExecHedged does, but for reads: it
+// races up to policy.Backup.MaxBackupRequests additional copies of the query
+// and scans the first row returned by whichever attempt wins into dest. The
+// winning iterator is closed after the scan, and any iterator opened by a
+// losing attempt is closed as it arrives instead of being left open.
+func (i *HedgedIter) ScanHedged(ctx context.Context, dest ...interface{}) bool {
+	if i.query.attempts() <= 1 {
+		iter := i.query.Query.WithContext(ctx).Iter()
+		defer iter.Close()
+		return iter.Scan(dest...)
+	}
+
+	iter, err := raceAttempts(ctx, i.query.attempts(), i.query.delay(), func(c context.Context) (*gocql.Iter, error) {
+		it := i.query.Query.WithContext(c).Iter()
+		if it.NumRows() == 0 {
+			if closeErr := it.Close(); closeErr != nil {
+				return nil, closeErr
+			}
+			return nil, gocql.ErrNotFound
+		}
+		return it, nil
+	}, func(it *gocql.Iter) {
+		it.Close()
+	})
+	if err != nil {
+		return false
+	}
+	defer iter.Close()
+	return iter.Scan(dest...)
+}
+
+// raceAttempts launches attempts copies of fn, staggering the i-th copy by
+// i*delay, and returns the value from the first copy to succeed. Once a
+// winner is found (or ctx is done), every other in-flight copy is cancelled.
+// discard, if non-nil, is called with the value of every successful attempt
+// that isn't the winner - including ones that finish after raceAttempts has
+// already returned - so callers whose T holds a resource (e.g. a
+// *gocql.Iter) can release it instead of leaking it.
+func raceAttempts[T any](ctx context.Context, attempts int, delay time.Duration, fn func(context.Context) (T, error), discard func(T)) (T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	type result struct {
+		val T
+		err error
+	}
+	results := make(chan result, attempts)
+
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i > 0 {
+				t := time.NewTimer(time.Duration(i) * delay)
+				defer t.Stop()
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					return
+				}
+			}
+			v, err := fn(ctx)
+			results <- result{val: v, err: err}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	lastErr := ctx.Err()
+	for r := range results {
+		if r.err == nil {
+			cancel()
+			if discard != nil {
+				go func() {
+					for straggler := range results {
+						if straggler.err == nil {
+							discard(straggler.val)
+						}
+					}
+				}()
+			}
+			return r.val, nil
+		}
+		lastErr = r.err
+	}
+	cancel()
+	var zero T
+	return zero, lastErr
+}
@@ -0,0 +1,90 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsIdempotent(t *testing.T) {
+	type testCase struct {
+		name   string
+		stmt   string
+		result bool
+	}
+
+	testCases := []testCase{
+		{"plain insert is idempotent", "INSERT INTO t (id, v) VALUES (?, ?)", true},
+		{"plain update is idempotent", "UPDATE t SET v = ? WHERE id = ?", true},
+		{"plain delete is idempotent", "DELETE FROM t WHERE id = ?", true},
+		{"insert if not exists is not idempotent", "INSERT INTO t (id, v) VALUES (?, ?) IF NOT EXISTS", false},
+		{"delete if exists is not idempotent", "DELETE FROM t WHERE id = ? IF EXISTS", false},
+		{"counter update is not idempotent", "UPDATE t SET counter_value = counter_value + 1 WHERE id = ?", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(te *testing.T) {
+			assert.Equal(te, tc.result, IsIdempotent(tc.stmt))
+		})
+	}
+}
+
+func TestRaceAttemptsReturnsFirstSuccess(t *testing.T) {
+	val, err := raceAttempts(context.Background(), 3, time.Millisecond, func(ctx context.Context) (int, error) {
+		return 7, nil
+	}, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 7, val)
+}
+
+func TestRaceAttemptsReturnsLastErrorWhenAllFail(t *testing.T) {
+	boom := errors.New("boom")
+	_, err := raceAttempts(context.Background(), 2, time.Millisecond, func(ctx context.Context) (int, error) {
+		return 0, boom
+	}, nil)
+
+	assert.ErrorIs(t, err, boom)
+}
+
+func TestRaceAttemptsDiscardsStragglers(t *testing.T) {
+	// A barrier makes all three attempts reach fn before any of them
+	// returns, so the winner's return (and the resulting cancellation)
+	// can't race ahead of the stragglers even starting. The winner then
+	// returns immediately while the stragglers sleep past it, simulating a
+	// backup request whose response arrives after the primary already won
+	// the race - those stragglers must be handed to discard instead of
+	// being left unread.
+	var calls int32
+	started := make(chan struct{}, 2)
+	discarded := make(chan int, 2)
+	val, err := raceAttempts(context.Background(), 3, 0, func(ctx context.Context) (int, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n != 1 {
+			started <- struct{}{}
+			time.Sleep(50 * time.Millisecond)
+			return 2, nil
+		}
+		<-started
+		<-started
+		return 1, nil
+	}, func(v int) {
+		discarded <- v
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, val)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case v := <-discarded:
+			assert.Equal(t, 2, v)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for straggler to be discarded")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// Observer receives callbacks about CosmosRetryPolicy's retry decisions, so
+// operators can alert on sustained 429 rates and correlate retries with
+// Cosmos DB ActivityIDs. Without an Observer the policy reports none of
+// this: it classifies every error silently.
+//
+// ctx is the RetryableQuery's context from the most recent call to Attempt,
+// so OTelObserver (or a custom implementation) can attach events to the
+// span the caller started for the query.
+type Observer interface {
+	// OnRetry is called whenever GetRetryType decides to retry, whether
+	// because of a recognized gocql timeout, a transient Cosmos DB
+	// response, or a predicate registered with WithRetryableErrors.
+	OnRetry(ctx context.Context, attempt int, delay time.Duration, err error)
+	// OnGiveUp is called whenever GetRetryType decides not to retry,
+	// including when the hard cap set with WithMaxAttempts was reached.
+	OnGiveUp(ctx context.Context, attempts int, err error)
+	// OnRateLimited is called whenever a Cosmos DB 429 response is
+	// recognized, in addition to OnRetry, so operators can track throttling
+	// specifically rather than retries in general.
+	OnRateLimited(ctx context.Context, retryAfter time.Duration, activityID string)
+	// OnCircuitBreakerStateChange is called whenever the circuit breaker
+	// configured with WithCircuitBreaker changes state, most importantly on
+	// the closed -> open transition operators want to alarm on.
+	OnCircuitBreakerStateChange(ctx context.Context, from, to CircuitBreakerState)
+}
+
+func (p *CosmosRetryPolicy) notifyRetry(ctx context.Context, attempts int, delay time.Duration, err error) {
+	if p.Observer != nil {
+		p.Observer.OnRetry(ctx, attempts, delay, err)
+	}
+}
+
+func (p *CosmosRetryPolicy) notifyGiveUp(ctx context.Context, attempts int, err error) {
+	if p.Observer != nil {
+		p.Observer.OnGiveUp(ctx, attempts, err)
+	}
+}
+
+func (p *CosmosRetryPolicy) notifyRateLimited(ctx context.Context, retryAfter time.Duration, activityID string) {
+	if p.Observer != nil {
+		p.Observer.OnRateLimited(ctx, retryAfter, activityID)
+	}
+}
+
+func (p *CosmosRetryPolicy) notifyCircuitBreakerStateChange(ctx context.Context, from, to CircuitBreakerState) {
+	if p.Observer != nil {
+		p.Observer.OnCircuitBreakerStateChange(ctx, from, to)
+	}
+}
@@ -0,0 +1,57 @@
+package retry
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OTelObserver is an Observer that records retry activity as span events on
+// the span carried by ctx (the RetryableQuery's Context, as passed through
+// CosmosRetryPolicy.Attempt), rather than shipping its own metrics. If ctx
+// carries no span, trace.SpanFromContext returns a no-op span and these
+// calls are harmless no-ops.
+type OTelObserver struct{}
+
+// NewOTelObserver creates an OTelObserver.
+func NewOTelObserver() *OTelObserver {
+	return &OTelObserver{}
+}
+
+// OnRetry implements Observer.
+func (o *OTelObserver) OnRetry(ctx context.Context, attempt int, delay time.Duration, err error) {
+	trace.SpanFromContext(ctx).AddEvent("cosmos_cassandra.retry", trace.WithAttributes(
+		attribute.Int("attempt", attempt),
+		attribute.Int64("delay_ms", delay.Milliseconds()),
+		attribute.String("error", errString(err)),
+	))
+}
+
+// OnGiveUp implements Observer.
+func (o *OTelObserver) OnGiveUp(ctx context.Context, attempts int, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent("cosmos_cassandra.give_up", trace.WithAttributes(
+		attribute.Int("attempts", attempts),
+		attribute.String("error", errString(err)),
+	))
+	span.SetStatus(codes.Error, errString(err))
+}
+
+// OnRateLimited implements Observer.
+func (o *OTelObserver) OnRateLimited(ctx context.Context, retryAfter time.Duration, activityID string) {
+	trace.SpanFromContext(ctx).AddEvent("cosmos_cassandra.rate_limited", trace.WithAttributes(
+		attribute.Int64("retry_after_ms", retryAfter.Milliseconds()),
+		attribute.String("activity_id", activityID),
+	))
+}
+
+// OnCircuitBreakerStateChange implements Observer.
+func (o *OTelObserver) OnCircuitBreakerStateChange(ctx context.Context, from, to CircuitBreakerState) {
+	trace.SpanFromContext(ctx).AddEvent("cosmos_cassandra.circuit_breaker_state_change", trace.WithAttributes(
+		attribute.String("from", from.String()),
+		attribute.String("to", to.String()),
+	))
+}
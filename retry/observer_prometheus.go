@@ -0,0 +1,87 @@
+package retry
+
+import (
+	"context"
+	"time"
+
+	"github.com/AzureAdvocateBit/cosmos-cassandra-go-extension-1/cosmoserr"
+	"github.com/gocql/gocql"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusObserver is an Observer that reports retry activity as
+// Prometheus metrics: a cosmos_cassandra_retries_total counter, labeled by
+// reason, and a cosmos_cassandra_retry_delay_seconds histogram of the
+// delays the policy computed.
+type PrometheusObserver struct {
+	retries            *prometheus.CounterVec
+	retryDelay         prometheus.Histogram
+	breakerTransitions *prometheus.CounterVec
+}
+
+// NewPrometheusObserver creates a PrometheusObserver and registers its
+// collectors with reg.
+func NewPrometheusObserver(reg prometheus.Registerer) *PrometheusObserver {
+	o := &PrometheusObserver{
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cosmos_cassandra_retries_total",
+			Help: "Total number of retries, give-ups and rate-limited responses seen by CosmosRetryPolicy, labeled by reason.",
+		}, []string{"reason"}),
+		retryDelay: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "cosmos_cassandra_retry_delay_seconds",
+			Help: "Delay, in seconds, computed by CosmosRetryPolicy before each retry.",
+		}),
+		breakerTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cosmos_cassandra_circuit_breaker_transitions_total",
+			Help: "Total number of circuit breaker state transitions, labeled by the state entered.",
+		}, []string{"to"}),
+	}
+	reg.MustRegister(o.retries, o.retryDelay, o.breakerTransitions)
+	return o
+}
+
+// OnRetry implements Observer.
+func (o *PrometheusObserver) OnRetry(ctx context.Context, attempt int, delay time.Duration, err error) {
+	o.retries.WithLabelValues(retryReason(err)).Inc()
+	o.retryDelay.Observe(delay.Seconds())
+}
+
+// OnGiveUp implements Observer.
+func (o *PrometheusObserver) OnGiveUp(ctx context.Context, attempts int, err error) {
+	o.retries.WithLabelValues("give_up").Inc()
+}
+
+// OnRateLimited implements Observer.
+func (o *PrometheusObserver) OnRateLimited(ctx context.Context, retryAfter time.Duration, activityID string) {
+	o.retries.WithLabelValues("rate_limited").Inc()
+}
+
+// OnCircuitBreakerStateChange implements Observer.
+func (o *PrometheusObserver) OnCircuitBreakerStateChange(ctx context.Context, from, to CircuitBreakerState) {
+	o.breakerTransitions.WithLabelValues(to.String()).Inc()
+}
+
+// retryReason classifies err for the "reason" metric label.
+func retryReason(err error) string {
+	if _, ok := cosmoserr.Parse(errString(err)); ok {
+		return "throttled"
+	}
+	switch err.(type) {
+	case *gocql.RequestErrReadTimeout:
+		return "read_timeout"
+	case *gocql.RequestErrWriteTimeout:
+		return "write_timeout"
+	case *gocql.RequestErrUnavailable:
+		return "unavailable"
+	default:
+		return "other"
+	}
+}
+
+// errString guards cosmoserr.Parse against a nil error.
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
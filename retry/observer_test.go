@@ -0,0 +1,76 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingObserver struct {
+	retries   int
+	giveUps   int
+	rateLimit int
+}
+
+func (o *recordingObserver) OnRetry(ctx context.Context, attempt int, delay time.Duration, err error) {
+	o.retries++
+}
+
+func (o *recordingObserver) OnGiveUp(ctx context.Context, attempts int, err error) {
+	o.giveUps++
+}
+
+func (o *recordingObserver) OnRateLimited(ctx context.Context, retryAfter time.Duration, activityID string) {
+	o.rateLimit++
+}
+
+func (o *recordingObserver) OnCircuitBreakerStateChange(ctx context.Context, from, to CircuitBreakerState) {
+}
+
+func TestObserverNotifiedOnRateLimitedRetry(t *testing.T) {
+	obs := &recordingObserver{}
+	p := NewCosmosRetryPolicy(5, WithObserver(obs))
+
+	p.GetRetryType(errors.New(rateLimitedErrMsg))
+
+	assert.Equal(t, 1, obs.retries)
+	assert.Equal(t, 1, obs.rateLimit)
+	assert.Equal(t, 0, obs.giveUps)
+}
+
+func TestObserverNotifiedOnGiveUp(t *testing.T) {
+	obs := &recordingObserver{}
+	p := NewCosmosRetryPolicy(5, WithObserver(obs))
+
+	p.GetRetryType(errors.New("error: today is not your day!"))
+
+	assert.Equal(t, 0, obs.retries)
+	assert.Equal(t, 1, obs.giveUps)
+}
+
+func TestObserverNotifiedOnMaxAttemptsExceeded(t *testing.T) {
+	obs := &recordingObserver{}
+	p := NewCosmosRetryPolicy(-1, WithMaxAttempts(1), WithObserver(obs))
+
+	// gocql always calls Attempt before GetRetryType for a given query; do
+	// the same here so the hard cap is evaluated against this query's own
+	// attempt count rather than a zero value.
+	p.Attempt(fixedAttemptsQuery{attempts: 1})
+	p.GetRetryType(errors.New(rateLimitedErrMsg))
+
+	assert.Equal(t, 1, obs.giveUps)
+	assert.ErrorIs(t, p.Err(), ErrMaxAttemptsExceeded)
+}
+
+// fixedAttemptsQuery is a MockRetryableQuery whose Attempts() can be set
+// explicitly, for tests that need to simulate a query already partway
+// through its retries.
+type fixedAttemptsQuery struct {
+	MockRetryableQuery
+	attempts int
+}
+
+func (q fixedAttemptsQuery) Attempts() int { return q.attempts }